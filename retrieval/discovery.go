@@ -0,0 +1,107 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/common/log"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// DiscoveryPlugin is implemented by third-party service discovery
+// mechanisms (Nomad, Azure, GCE, Docker Swarm, etc.) that want to be wired
+// into a scrape job without being built into the config package directly.
+//
+// A plugin only ever sees the ScrapeConfig fields that already exist (job
+// name, scheme, relabeling rules, ...); there is no generic sd_configs:
+// block an operator can use to hand a plugin its own settings from
+// prometheus.yml. In practice this means a plugin can only decide whether
+// it applies to a job from that job's existing fields, typically its
+// JobName, and any plugin-specific settings (API address, ACL token,
+// region, ...) must be supplied at registration time in Go rather than in
+// configuration.
+type DiscoveryPlugin interface {
+	// NewDiscoverer creates a TargetProvider for the given scrape
+	// configuration, or returns a nil TargetProvider if the plugin is not
+	// configured for cfg. Implementations are expected to pull whatever
+	// plugin-specific settings they need out of cfg themselves.
+	NewDiscoverer(cfg *config.ScrapeConfig) (TargetProvider, error)
+}
+
+// Closer may optionally be implemented by a TargetProvider to release any
+// resources it holds, e.g. open connections to a discovery backend, once
+// ApplyConfig replaces it with a new provider.
+type Closer interface {
+	Close()
+}
+
+var (
+	discoveryMtx   sync.Mutex
+	discoveryNames = map[string]DiscoveryPlugin{}
+)
+
+// RegisterDiscovery registers a third-party DiscoveryPlugin under name so it
+// is consulted for every scrape job's configuration. It is intended to be
+// called from a plugin package's init function, with any plugin-specific
+// settings supplied as Go fields on the plugin itself rather than through
+// prometheus.yml:
+//
+//	func init() {
+//		retrieval.RegisterDiscovery("nomad", &nomadPlugin{Address: "http://localhost:4646"})
+//	}
+//
+// RegisterDiscovery panics if name is already registered.
+func RegisterDiscovery(name string, plugin DiscoveryPlugin) {
+	discoveryMtx.Lock()
+	defer discoveryMtx.Unlock()
+
+	if _, ok := discoveryNames[name]; ok {
+		panic(fmt.Sprintf("retrieval: discovery plugin %q already registered", name))
+	}
+	discoveryNames[name] = plugin
+}
+
+// unregisterDiscovery removes a previously registered plugin. It exists
+// only to let tests install a fake plugin without leaking it into other
+// test cases.
+func unregisterDiscovery(name string) {
+	discoveryMtx.Lock()
+	defer discoveryMtx.Unlock()
+
+	delete(discoveryNames, name)
+}
+
+// pluginProvidersFromConfig returns the TargetProviders created by all
+// registered discovery plugins that are configured for cfg, keyed in the
+// same "<mechanism>/<index>" scheme as the built-in mechanisms.
+func pluginProvidersFromConfig(cfg *config.ScrapeConfig, app func(mech string, i int, tp TargetProvider)) {
+	discoveryMtx.Lock()
+	defer discoveryMtx.Unlock()
+
+	for name, plugin := range discoveryNames {
+		tp, err := plugin.NewDiscoverer(cfg)
+		if err != nil {
+			log.Errorf("Cannot create %s discovery: %s", name, err)
+			continue
+		}
+		if tp == nil {
+			// The plugin is not configured for this scrape job.
+			continue
+		}
+		app(name, 0, tp)
+	}
+}
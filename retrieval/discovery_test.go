@@ -0,0 +1,105 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// fakeDiscovery is a TargetProvider created by fakePlugin. It immediately
+// emits a single static target group and records whether it was closed.
+type fakeDiscovery struct {
+	address string
+	closed  bool
+}
+
+func (fd *fakeDiscovery) Run(ctx context.Context, ch chan<- []*config.TargetGroup) {
+	select {
+	case ch <- []*config.TargetGroup{{Source: "fake/0", Targets: []model.LabelSet{
+		{model.AddressLabel: model.LabelValue(fd.address)},
+	}}}:
+	case <-ctx.Done():
+	}
+	<-ctx.Done()
+	close(ch)
+}
+
+func (fd *fakeDiscovery) Close() {
+	fd.closed = true
+}
+
+// fakePlugin only fires for the scrape job it was built for. Its address is
+// set in Go at registration time, mirroring the fact that a DiscoveryPlugin
+// has no generic sd_configs: block to read plugin-specific settings from;
+// see DiscoveryPlugin's doc comment.
+type fakePlugin struct {
+	job     string
+	address string
+	created []*fakeDiscovery
+}
+
+func (fp *fakePlugin) NewDiscoverer(cfg *config.ScrapeConfig) (TargetProvider, error) {
+	if cfg.JobName != fp.job {
+		return nil, nil
+	}
+	fd := &fakeDiscovery{address: fp.address}
+	fp.created = append(fp.created, fd)
+	return fd, nil
+}
+
+func TestRegisterDiscoveryWiresPluginIntoTargetSet(t *testing.T) {
+	plugin := &fakePlugin{job: "fake_job", address: "localhost:1234"}
+	RegisterDiscovery("fake", plugin)
+	defer unregisterDiscovery("fake")
+
+	scfg := &config.ScrapeConfig{
+		JobName: "fake_job",
+		Scheme:  "http",
+	}
+
+	providers := providersFromConfig(scfg)
+	if _, ok := providers["fake/0"]; !ok {
+		t.Fatalf("expected providersFromConfig to include the registered plugin, got %v", providers)
+	}
+
+	ts := newTargetSet(scfg, nil, defaultMinSyncInterval)
+	ctx, cancel := context.WithCancel(context.Background())
+	ts.runProviders(ctx, providers)
+
+	if len(plugin.created) != 1 {
+		t.Fatalf("expected plugin to create exactly one discoverer, got %d", len(plugin.created))
+	}
+	if len(ts.tgroups) == 0 {
+		t.Fatalf("expected the fake discovery's target group to be synced into the target set")
+	}
+
+	cancel()
+	ts.scrapePool.stop()
+}
+
+func TestRegisterDiscoverySkipsUnconfiguredJob(t *testing.T) {
+	plugin := &fakePlugin{job: "fake_job", address: "localhost:1234"}
+	RegisterDiscovery("fake", plugin)
+	defer unregisterDiscovery("fake")
+
+	providers := providersFromConfig(&config.ScrapeConfig{JobName: "other_job", Scheme: "http"})
+	if _, ok := providers["fake/0"]; ok {
+		t.Fatalf("expected the plugin to be skipped for a job it isn't configured for, got %v", providers)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestGroupBufferCoalescesBySource(t *testing.T) {
+	buf := newGroupBuffer()
+
+	first := &config.TargetGroup{Source: "a"}
+	second := &config.TargetGroup{Source: "a"}
+
+	if dropped := buf.push(first); dropped {
+		t.Fatalf("did not expect the first push for a source to report a drop")
+	}
+	if dropped := buf.push(second); !dropped {
+		t.Fatalf("expected the second push for the same source to supersede the first")
+	}
+
+	groups := buf.drain()
+	if len(groups) != 1 {
+		t.Fatalf("expected exactly one pending group per source, got %d", len(groups))
+	}
+	if groups[0] != second {
+		t.Fatalf("expected drain to return the most recently pushed group")
+	}
+}
+
+func TestGroupBufferDrainClearsPending(t *testing.T) {
+	buf := newGroupBuffer()
+	buf.push(&config.TargetGroup{Source: "a"})
+	buf.push(&config.TargetGroup{Source: "b"})
+
+	if groups := buf.drain(); len(groups) != 2 {
+		t.Fatalf("expected both sources to be returned, got %d", len(groups))
+	}
+	if groups := buf.drain(); len(groups) != 0 {
+		t.Fatalf("expected drain to clear pending groups, got %d left", len(groups))
+	}
+}
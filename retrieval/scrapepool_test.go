@@ -0,0 +1,113 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func mustNewTestTarget(t *testing.T, address string) *Target {
+	cfg := &config.ScrapeConfig{JobName: "test", Scheme: "http"}
+	labels := model.LabelSet{
+		model.AddressLabel: model.LabelValue(address),
+		model.SchemeLabel:  "http",
+		model.JobLabel:     "test",
+	}
+	tgt, err := NewTarget(cfg, labels, labels)
+	if err != nil {
+		t.Fatalf("error creating test target: %s", err)
+	}
+	return tgt
+}
+
+func TestScrapePoolSyncDeduplicatesAcrossSources(t *testing.T) {
+	sp := newScrapePool(nopAppender{})
+
+	tgt := mustNewTestTarget(t, "localhost:1")
+	fp := tgt.fingerprint()
+
+	// The same target is discovered through two sources at once, e.g. a
+	// static config and a Consul discovery during a migration.
+	sp.sync(map[string]map[model.Fingerprint]*Target{
+		"static/0": {fp: tgt},
+		"consul/0": {fp: tgt},
+	})
+
+	if len(sp.targets) != 1 {
+		t.Fatalf("expected exactly one target after deduplication, got %d", len(sp.targets))
+	}
+}
+
+func TestScrapePoolSyncKeepsScraperOnSourceMove(t *testing.T) {
+	sp := newScrapePool(nopAppender{})
+
+	tgt := mustNewTestTarget(t, "localhost:1")
+	fp := tgt.fingerprint()
+
+	sp.sync(map[string]map[model.Fingerprint]*Target{
+		"static/0": {fp: tgt},
+	})
+	running := sp.targets[fp]
+
+	// The target moves from the static source to a Consul source. Since its
+	// fingerprint is unchanged, the existing scraper must keep running
+	// rather than being stopped and restarted.
+	moved := mustNewTestTarget(t, "localhost:1")
+	sp.sync(map[string]map[model.Fingerprint]*Target{
+		"consul/0": {fp: moved},
+	})
+
+	if sp.targets[fp] != running {
+		t.Fatalf("expected the original target instance to be preserved across a source move")
+	}
+}
+
+func TestScrapePoolSyncRemovesDisappearedSource(t *testing.T) {
+	sp := newScrapePool(nopAppender{})
+
+	tgt := mustNewTestTarget(t, "localhost:1")
+	fp := tgt.fingerprint()
+
+	sp.sync(map[string]map[model.Fingerprint]*Target{
+		"static/0": {fp: tgt},
+	})
+	sp.sync(map[string]map[model.Fingerprint]*Target{})
+
+	if len(sp.targets) != 0 {
+		t.Fatalf("expected target to be removed once its source disappears, got %d remaining", len(sp.targets))
+	}
+}
+
+func TestScrapePoolSyncPrecedenceIsDeterministic(t *testing.T) {
+	sp := newScrapePool(nopAppender{})
+
+	tgtA := mustNewTestTarget(t, "localhost:1")
+	tgtB := mustNewTestTarget(t, "localhost:1")
+	fp := tgtA.fingerprint()
+
+	sp.sync(map[string]map[model.Fingerprint]*Target{
+		"b_source/0": {fp: tgtB},
+		"a_source/0": {fp: tgtA},
+	})
+
+	// "a_source/0" sorts before "b_source/0", so it wins precedence for a
+	// newly discovered fingerprint.
+	if sp.targets[fp] != tgtA {
+		t.Fatalf("expected the lexicographically first source to win precedence")
+	}
+}
@@ -15,10 +15,13 @@ package retrieval
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/model"
 	"golang.org/x/net/context"
@@ -28,6 +31,32 @@ import (
 	"github.com/prometheus/prometheus/storage"
 )
 
+// defaultMinSyncInterval is the minimum amount of time a targetSet waits
+// between two consecutive syncs once the first one in a burst has fired.
+const defaultMinSyncInterval = 5 * time.Second
+
+var (
+	targetSyncLengthSeconds = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name: "prometheus_target_sync_length_seconds",
+			Help: "Actual interval to sync the scrape pool.",
+		},
+		[]string{"scrape_job"},
+	)
+	targetUpdatesDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prometheus_target_updates_dropped_total",
+			Help: "Total number of target group updates replaced by a newer update for the same source before they could be applied.",
+		},
+		[]string{"scrape_job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(targetSyncLengthSeconds)
+	prometheus.MustRegister(targetUpdatesDropped)
+}
+
 // A TargetProvider provides information about target groups. It maintains a set
 // of sources from which TargetGroups can originate. Whenever a target provider
 // detects a potential change, it sends the TargetGroup through its provided channel.
@@ -56,6 +85,11 @@ type TargetManager struct {
 	cancel func()
 	wg     sync.WaitGroup
 
+	// minSyncInterval is the minimum amount of time a targetSet waits between
+	// two consecutive syncs once the first one in a burst has fired. It
+	// applies to every target set created by this manager.
+	minSyncInterval time.Duration
+
 	// Set of unqiue targets by scrape configuration.
 	targetSets map[string]*targetSet
 }
@@ -63,51 +97,32 @@ type TargetManager struct {
 // NewTargetManager creates a new TargetManager.
 func NewTargetManager(app storage.SampleAppender) *TargetManager {
 	return &TargetManager{
-		appender:   app,
-		targetSets: map[string]*targetSet{},
+		appender:        app,
+		targetSets:      map[string]*targetSet{},
+		minSyncInterval: defaultMinSyncInterval,
 	}
 }
 
+// SetMinSyncInterval sets the minimum amount of time that must pass between
+// two consecutive target group syncs for any job this manager runs. It must
+// be called before Run, and takes effect for target sets created from then
+// on; it does not affect jobs that are already running.
+func (tm *TargetManager) SetMinSyncInterval(d time.Duration) {
+	tm.mtx.Lock()
+	defer tm.mtx.Unlock()
+	tm.minSyncInterval = d
+}
+
 // Run starts background processing to handle target updates.
 func (tm *TargetManager) Run() {
 	log.Info("Starting target manager...")
 
 	tm.mtx.Lock()
 	tm.ctx, tm.cancel = context.WithCancel(context.Background())
-
-	jobs := map[string]struct{}{}
-
-	// Start new target sets and update existing ones.
-	for _, scfg := range tm.scrapeConfigs {
-		jobs[scfg.JobName] = struct{}{}
-
-		ts, ok := tm.targetSets[scfg.JobName]
-		if !ok {
-			ts = newTargetSet(scfg, tm.appender)
-			tm.targetSets[scfg.JobName] = ts
-		}
-		ts.runProviders(tm.ctx, providersFromConfig(scfg))
-	}
-
-	// Stop old target sets.
-	for name := range tm.targetSets {
-		if _, ok := jobs[name]; !ok {
-			delete(tm.targetSets, name)
-		}
-	}
-
-	// Run target sets.
-	for _, ts := range tm.targetSets {
-		tm.wg.Add(1)
-
-		go func(ts *targetSet) {
-			ts.run(tm.ctx)
-			tm.wg.Done()
-		}(ts)
-	}
-
 	tm.mtx.Unlock()
 
+	tm.applyScrapeConfigs(tm.scrapeConfigs)
+
 	tm.wg.Wait()
 }
 
@@ -137,36 +152,146 @@ func (tm *TargetManager) Pools() map[string][]*Target {
 
 	// TODO(fabxc): this is just a hack to maintain compatibility for now.
 	for _, ps := range tm.targetSets {
-		for _, ts := range ps.scrapePool.tgroups {
-			for _, t := range ts {
-				job := string(t.Labels()[model.JobLabel])
-				pools[job] = append(pools[job], t)
-			}
+		for _, t := range ps.scrapePool.targets {
+			job := string(t.Labels()[model.JobLabel])
+			pools[job] = append(pools[job], t)
 		}
 	}
 	return pools
 }
 
 // ApplyConfig resets the manager's target providers and job configurations as defined
-// by the new cfg. The state of targets that are valid in the new configuration remains unchanged.
-// Returns true on success.
+// by the new cfg. The state of targets that are valid in the new configuration remains
+// unchanged. Jobs whose scrape configuration didn't change keep their target providers,
+// discovery watches and running scrapers untouched; only jobs that actually changed are
+// affected. Returns true on success.
 func (tm *TargetManager) ApplyConfig(cfg *config.Config) bool {
-	tm.mtx.RLock()
+	tm.mtx.Lock()
+	tm.scrapeConfigs = cfg.ScrapeConfigs
 	running := tm.ctx != nil
-	tm.mtx.RUnlock()
+	tm.mtx.Unlock()
 
-	if running {
-		tm.Stop()
-		defer func() {
-			go tm.Run()
-		}()
+	if !running {
+		// Run will pick up tm.scrapeConfigs once started.
+		return true
 	}
 
+	tm.applyScrapeConfigs(cfg.ScrapeConfigs)
+	return true
+}
+
+// applyScrapeConfigs reconciles the running target sets with cfgs. Jobs that
+// no longer exist are stopped, brand new jobs are started, and jobs whose
+// configuration changed are updated with as little disruption as possible:
+// a change to only relabeling, scrape interval or timeout reconfigures the
+// existing target set in place, while a change to the discovery mechanisms
+// themselves restarts that job's target providers without touching any
+// other job.
+func (tm *TargetManager) applyScrapeConfigs(cfgs []*config.ScrapeConfig) {
 	tm.mtx.Lock()
-	tm.scrapeConfigs = cfg.ScrapeConfigs
-	tm.mtx.Unlock()
+	defer tm.mtx.Unlock()
 
-	return true
+	jobs := make(map[string]*config.ScrapeConfig, len(cfgs))
+	for _, scfg := range cfgs {
+		jobs[scfg.JobName] = scfg
+	}
+
+	// Stop and drop target sets for jobs that no longer exist.
+	for name, ts := range tm.targetSets {
+		if _, ok := jobs[name]; !ok {
+			ts.stop()
+			delete(tm.targetSets, name)
+		}
+	}
+
+	for name, scfg := range jobs {
+		ts, ok := tm.targetSets[name]
+		if !ok {
+			// A brand new job: create its target set and start scraping.
+			ts = newTargetSet(scfg, tm.appender, tm.minSyncInterval)
+			ts.ctx, ts.cancel = context.WithCancel(tm.ctx)
+			tm.targetSets[name] = ts
+
+			tm.wg.Add(1)
+			go func(ts *targetSet) {
+				ts.run()
+				tm.wg.Done()
+			}(ts)
+
+			ts.runProviders(ts.ctx, providersFromConfig(scfg))
+			continue
+		}
+
+		if reflect.DeepEqual(ts.config, scfg) {
+			// Nothing changed for this job; leave its providers, targets and
+			// running scrapers untouched.
+			continue
+		}
+
+		if ts.sameDiscoveryConfig(ts.config, scfg) {
+			// Only relabeling, scrape interval or timeout changed. Reconfigure
+			// the existing scrape pool in place instead of tearing down
+			// in-flight scrapes and discovery watches.
+			ts.reload(scfg)
+			continue
+		}
+
+		// The discovery mechanisms changed: restart this job's target
+		// providers. Every other job is left running.
+		ts.runProviders(ts.ctx, providersFromConfig(scfg))
+	}
+}
+
+// sameDiscoveryConfig reports whether a and b configure the exact same set of
+// target providers. It ignores fields like relabeling rules, scrape interval
+// and timeout, which affect how discovered targets are scraped but not which
+// discovery mechanisms are running.
+//
+// This can't account for registered DiscoveryPlugins, which are free to key
+// their configuration off of any field of a ScrapeConfig: if ts currently
+// runs a plugin-backed provider, it is conservatively always treated as
+// "changed" by restarting it through runProviders, which is a correct but
+// potentially redundant reload. Jobs that don't use a registered plugin are
+// unaffected.
+func (ts *targetSet) sameDiscoveryConfig(a, b *config.ScrapeConfig) bool {
+	same := reflect.DeepEqual(a.DNSSDConfigs, b.DNSSDConfigs) &&
+		reflect.DeepEqual(a.FileSDConfigs, b.FileSDConfigs) &&
+		reflect.DeepEqual(a.ConsulSDConfigs, b.ConsulSDConfigs) &&
+		reflect.DeepEqual(a.MarathonSDConfigs, b.MarathonSDConfigs) &&
+		reflect.DeepEqual(a.KubernetesSDConfigs, b.KubernetesSDConfigs) &&
+		reflect.DeepEqual(a.ServersetSDConfigs, b.ServersetSDConfigs) &&
+		reflect.DeepEqual(a.NerveSDConfigs, b.NerveSDConfigs) &&
+		reflect.DeepEqual(a.EC2SDConfigs, b.EC2SDConfigs) &&
+		reflect.DeepEqual(a.TargetGroups, b.TargetGroups)
+	if !same {
+		return false
+	}
+	return !ts.usesPluginProvider()
+}
+
+// usesPluginProvider reports whether any of ts's currently running target
+// providers were created by a registered DiscoveryPlugin.
+func (ts *targetSet) usesPluginProvider() bool {
+	// Snapshot the provider names under ts.mtx, which is what guards
+	// ts.providers everywhere else (e.g. runProviders), then check them
+	// against discoveryNames under discoveryMtx. The two locks are taken
+	// one at a time, never nested, so there's no ordering to get wrong.
+	ts.mtx.RLock()
+	names := make([]string, 0, len(ts.providers))
+	for name := range ts.providers {
+		names = append(names, name)
+	}
+	ts.mtx.RUnlock()
+
+	discoveryMtx.Lock()
+	defer discoveryMtx.Unlock()
+
+	for _, name := range names {
+		if _, ok := discoveryNames[name]; ok {
+			return true
+		}
+	}
+	return false
 }
 
 // targetSet holds several TargetProviders for which the same scrape configuration
@@ -175,43 +300,64 @@ func (tm *TargetManager) ApplyConfig(cfg *config.Config) bool {
 type targetSet struct {
 	mtx       sync.RWMutex
 	tgroups   map[string]map[model.Fingerprint]*Target
+	rawGroups map[string]*config.TargetGroup
 	providers map[string]TargetProvider
 
 	scrapePool *scrapePool
 	config     *config.ScrapeConfig
 
+	// ctx and cancel scope this target set's providers and run loop so a
+	// single job can be stopped without affecting any other job.
+	ctx    context.Context
+	cancel func()
+
+	// minSyncInterval is the minimum amount of time that must pass between
+	// two syncs once the first one in a burst has fired.
+	minSyncInterval time.Duration
+
 	stopProviders func()
 	syncCh        chan struct{}
 }
 
-func newTargetSet(cfg *config.ScrapeConfig, app storage.SampleAppender) *targetSet {
+func newTargetSet(cfg *config.ScrapeConfig, app storage.SampleAppender, minSyncInterval time.Duration) *targetSet {
 	ts := &targetSet{
-		tgroups:    map[string]map[model.Fingerprint]*Target{},
-		scrapePool: newScrapePool(app),
-		syncCh:     make(chan struct{}, 1),
-		config:     cfg,
+		tgroups:         map[string]map[model.Fingerprint]*Target{},
+		rawGroups:       map[string]*config.TargetGroup{},
+		scrapePool:      newScrapePool(app),
+		syncCh:          make(chan struct{}, 1),
+		config:          cfg,
+		minSyncInterval: minSyncInterval,
 	}
 	return ts
 }
 
-func (ts *targetSet) run(ctx context.Context) {
-	ts.scrapePool.ctx = ctx
+// run applies target group updates as they come in on syncCh. It syncs
+// immediately for the first update after a quiet period, then rate-limits
+// to at most one sync per minSyncInterval so a burst of changes coalesces
+// into a single sync instead of queuing work or stalling for a fixed delay.
+func (ts *targetSet) run() {
+	ts.scrapePool.ctx = ts.ctx
+
+	var lastSync time.Time
 
 Loop:
 	for {
-		// Throttle syncing to once per five seconds.
 		select {
-		case <-ctx.Done():
+		case <-ts.ctx.Done():
 			break Loop
-		case <-time.After(5 * time.Second):
+		case <-ts.syncCh:
 		}
 
-		select {
-		case <-ctx.Done():
-			break Loop
-		case <-ts.syncCh:
-			ts.sync()
+		if wait := ts.minSyncInterval - time.Since(lastSync); wait > 0 {
+			select {
+			case <-ts.ctx.Done():
+				break Loop
+			case <-time.After(wait):
+			}
 		}
+
+		ts.sync()
+		lastSync = time.Now()
 	}
 
 	// We want to wait for all pending target scrapes to complete though to ensure there'll
@@ -219,10 +365,47 @@ Loop:
 	ts.scrapePool.stop()
 }
 
+// stop terminates this job's target providers, scrapers and run loop without
+// affecting any other job.
+func (ts *targetSet) stop() {
+	ts.cancel()
+}
+
 func (ts *targetSet) sync() {
-	// TODO(fabxc): temporary simple version. For a deduplicating scrape pool we will
-	// submit a list of all targets.
+	start := time.Now()
 	ts.scrapePool.sync(ts.tgroups)
+	targetSyncLengthSeconds.WithLabelValues(ts.jobName()).Observe(time.Since(start).Seconds())
+}
+
+// jobName returns the job name of the target set's current configuration.
+// It may be called concurrently with reload(), which replaces ts.config.
+func (ts *targetSet) jobName() string {
+	ts.mtx.RLock()
+	defer ts.mtx.RUnlock()
+	return ts.config.JobName
+}
+
+// reload recomputes all targets using cfg and propagates it to the running
+// scrape pool, without touching target providers, discovery watches or
+// in-flight scrapes. It is used when only relabeling, scrape interval or
+// timeout changed for this job.
+func (ts *targetSet) reload(cfg *config.ScrapeConfig) {
+	ts.mtx.Lock()
+	ts.config = cfg
+
+	for key, tg := range ts.rawGroups {
+		if err := ts.storeGroup(key, tg); err != nil {
+			log.With("target_group", tg).Errorf("Target update failed: %s", err)
+		}
+	}
+	ts.mtx.Unlock()
+
+	ts.scrapePool.reload(cfg)
+
+	select {
+	case ts.syncCh <- struct{}{}:
+	default:
+	}
 }
 
 func (ts *targetSet) runProviders(ctx context.Context, providers map[string]TargetProvider) {
@@ -237,6 +420,30 @@ func (ts *targetSet) runProviders(ctx context.Context, providers map[string]Targ
 	if ts.stopProviders != nil {
 		ts.stopProviders()
 	}
+	// Release any resources held by the providers we are about to replace,
+	// e.g. open connections to a discovery backend.
+	for _, prov := range ts.providers {
+		if closer, ok := prov.(Closer); ok {
+			closer.Close()
+		}
+	}
+	// Drop any target groups that came from a provider we are replacing.
+	// Otherwise targets discovered by a mechanism a job no longer uses (e.g.
+	// it switched from dns_sd_configs to consul_sd_configs) would stay in
+	// ts.tgroups and keep being scraped forever.
+	for name := range ts.providers {
+		if _, ok := providers[name]; ok {
+			continue
+		}
+		prefix := name + "/"
+		for key := range ts.rawGroups {
+			if strings.HasPrefix(key, prefix) {
+				delete(ts.rawGroups, key)
+				delete(ts.tgroups, key)
+			}
+		}
+	}
+	ts.providers = providers
 	ctx, ts.stopProviders = context.WithCancel(ctx)
 
 	for name, prov := range providers {
@@ -259,24 +466,34 @@ func (ts *targetSet) runProviders(ctx context.Context, providers map[string]Targ
 			}
 
 			for _, tgroup := range initial {
-				targets, err := targetsFromGroup(tgroup, ts.config)
-				if err != nil {
+				if err := ts.storeGroup(name+"/"+tgroup.Source, tgroup); err != nil {
 					log.With("target_group", tgroup).Errorf("Target update failed: %s", err)
-					continue
 				}
-				ts.tgroups[name+"/"+tgroup.Source] = targets
 			}
 
 			wg.Done()
 
-			// Start listening for further updates.
+			// Read updates as fast as the provider sends them so a bursty
+			// discovery backend never blocks on us, coalescing them by
+			// source in buf and applying at whatever pace buf.notify fires.
+			buf := newGroupBuffer()
+
 			for {
 				select {
 				case <-ctx.Done():
 					return
-				case tgs := <-updates:
+				case tgs, ok := <-updates:
+					if !ok {
+						return
+					}
 					for _, tg := range tgs {
-						if err := ts.update(name, tg); err != nil {
+						if buf.push(tg) {
+							targetUpdatesDropped.WithLabelValues(ts.jobName()).Inc()
+						}
+					}
+				case <-buf.notify:
+					for _, tg := range buf.drain() {
+						if err := ts.update(name+"/"+tg.Source, tg); err != nil {
 							log.With("target_group", tg).Errorf("Target update failed: %s", err)
 						}
 					}
@@ -292,17 +509,74 @@ func (ts *targetSet) runProviders(ctx context.Context, providers map[string]Targ
 	ts.sync()
 }
 
-// update handles a target group update from a target provider identified by the name.
-func (ts *targetSet) update(name string, tgroup *config.TargetGroup) error {
+// groupBuffer coalesces the target groups sent by a single provider by
+// source, keeping only the most recently pushed group for each source. It
+// lets a provider goroutine read updates off its channel as fast as they
+// arrive instead of blocking on whatever is consuming them, while ensuring a
+// bursty discovery backend never causes unbounded memory growth or
+// redundant work re-materializing superseded groups.
+//
+// A groupBuffer is only ever pushed to and drained from the single goroutine
+// that owns it, so it needs no locking of its own.
+type groupBuffer struct {
+	pending map[string]*config.TargetGroup
+	notify  chan struct{}
+}
+
+func newGroupBuffer() *groupBuffer {
+	return &groupBuffer{
+		pending: map[string]*config.TargetGroup{},
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// push stores tg, superseding any not yet applied group for the same
+// source, and reports whether such a group was dropped.
+func (b *groupBuffer) push(tg *config.TargetGroup) (dropped bool) {
+	_, dropped = b.pending[tg.Source]
+	b.pending[tg.Source] = tg
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+	return dropped
+}
+
+// drain returns and clears all currently pending groups.
+func (b *groupBuffer) drain() []*config.TargetGroup {
+	groups := make([]*config.TargetGroup, 0, len(b.pending))
+	for _, tg := range b.pending {
+		groups = append(groups, tg)
+	}
+	b.pending = map[string]*config.TargetGroup{}
+	return groups
+}
+
+// storeGroup computes targets for tgroup using the target set's current
+// configuration and stores them, along with the raw group, under key.
+// Callers must hold ts.mtx.
+func (ts *targetSet) storeGroup(key string, tgroup *config.TargetGroup) error {
 	targets, err := targetsFromGroup(tgroup, ts.config)
 	if err != nil {
 		return err
 	}
 
+	ts.rawGroups[key] = tgroup
+	ts.tgroups[key] = targets
+
+	return nil
+}
+
+// update handles a target group update from a target provider, stored under key
+// (typically "<provider name>/<group source>").
+func (ts *targetSet) update(key string, tgroup *config.TargetGroup) error {
 	ts.mtx.Lock()
 	defer ts.mtx.Unlock()
 
-	ts.tgroups[name+"/"+tgroup.Source] = targets
+	if err := ts.storeGroup(key, tgroup); err != nil {
+		return err
+	}
 
 	select {
 	case ts.syncCh <- struct{}{}:
@@ -312,19 +586,25 @@ func (ts *targetSet) update(name string, tgroup *config.TargetGroup) error {
 	return nil
 }
 
-// scrapePool manages scrapes for sets of targets.
+// scrapePool manages scrapes for a deduplicated set of targets drawn from
+// potentially several sources (providers) of a single job.
 type scrapePool struct {
 	appender storage.SampleAppender
 
-	ctx     context.Context
-	mtx     sync.RWMutex
-	tgroups map[string]map[model.Fingerprint]*Target
+	ctx context.Context
+	mtx sync.RWMutex
+
+	// targets holds the single, deduplicated set of targets currently being
+	// scraped, keyed by fingerprint. A target discovered through more than
+	// one source (e.g. a static config and Consul during a migration, or
+	// overlapping Kubernetes selectors) is only ever scraped once.
+	targets map[model.Fingerprint]*Target
 }
 
 func newScrapePool(app storage.SampleAppender) *scrapePool {
 	return &scrapePool{
 		appender: app,
-		tgroups:  map[string]map[model.Fingerprint]*Target{},
+		targets:  map[model.Fingerprint]*Target{},
 	}
 }
 
@@ -333,65 +613,73 @@ func (sp *scrapePool) stop() {
 
 	sp.mtx.RLock()
 
-	for _, tgroup := range sp.tgroups {
-		for _, t := range tgroup {
-			wg.Add(1)
+	for _, t := range sp.targets {
+		wg.Add(1)
 
-			go func(t *Target) {
-				t.StopScraper()
-				wg.Done()
-			}(t)
-		}
+		go func(t *Target) {
+			t.StopScraper()
+			wg.Done()
+		}(t)
 	}
 	sp.mtx.RUnlock()
 
 	wg.Wait()
 }
 
+// reload propagates cfg to every target currently running in the pool, e.g.
+// after a relabeling, scrape interval or timeout change, without restarting
+// their scrapers.
+func (sp *scrapePool) reload(cfg *config.ScrapeConfig) {
+	sp.mtx.RLock()
+	defer sp.mtx.RUnlock()
+
+	for _, t := range sp.targets {
+		t.SetScrapeConfig(cfg)
+	}
+}
+
+// sync merges tgroups, which holds one set of targets per source, into the
+// pool's single deduplicated set of targets and starts or stops scrapers as
+// needed. A target that simply moves from one source to another between two
+// calls to sync keeps its existing scraper running.
 func (sp *scrapePool) sync(tgroups map[string]map[model.Fingerprint]*Target) {
 	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+
+	// Merge all sources into a single set of targets. If the same
+	// fingerprint is provided by more than one source, the source that
+	// sorts first by name takes precedence for its label set; iterating in
+	// a fixed order keeps that choice deterministic across runs.
+	sources := make([]string, 0, len(tgroups))
+	for source := range tgroups {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
 
-	var (
-		wg         sync.WaitGroup
-		newTgroups = map[string]map[model.Fingerprint]*Target{}
-	)
-
-	for source, targets := range tgroups {
-		var (
-			prevTargets = sp.tgroups[source]
-			newTargets  = map[model.Fingerprint]*Target{}
-		)
-		newTgroups[source] = newTargets
-
-		for fp, tnew := range targets {
-			// If the same target existed before, we let it run and replace
-			// the new one with it.
-			if told, ok := prevTargets[fp]; ok {
-				newTargets[fp] = told
-			} else {
-				newTargets[fp] = tnew
-				go tnew.RunScraper(sp.appender)
-			}
-		}
-		for fp, told := range targets {
-			// A previous target is no longer in the group.
-			if _, ok := targets[fp]; !ok {
-				wg.Add(1)
-
-				go func(told *Target) {
-					told.StopScraper()
-					wg.Done()
-				}(told)
+	merged := make(map[model.Fingerprint]*Target, len(sp.targets))
+	for _, source := range sources {
+		for fp, t := range tgroups[source] {
+			if _, ok := merged[fp]; !ok {
+				merged[fp] = t
 			}
 		}
 	}
 
-	// Stop scrapers for target groups that disappeared completely.
-	for source, targets := range sp.tgroups {
-		if _, ok := tgroups[source]; !ok {
+	var wg sync.WaitGroup
+
+	for fp, tnew := range merged {
+		// If the target already runs, possibly under a different source, we
+		// let it keep running instead of restarting its scraper.
+		if told, ok := sp.targets[fp]; ok {
+			merged[fp] = told
 			continue
 		}
-		for _, told := range targets {
+		go tnew.RunScraper(sp.appender)
+	}
+
+	for fp, told := range sp.targets {
+		// The target is no longer provided by any source.
+		if _, ok := merged[fp]; !ok {
 			wg.Add(1)
 
 			go func(told *Target) {
@@ -401,16 +689,13 @@ func (sp *scrapePool) sync(tgroups map[string]map[model.Fingerprint]*Target) {
 		}
 	}
 
-	sp.tgroups = newTgroups
+	sp.targets = merged
 
 	// Wait for all potentially stopped scrapers to terminate.
 	// This covers the case of flapping targets. If the server is under high load, a new scraper
 	// may be active and tries to insert. The old scraper that didn't terminate yet could still
 	// be inserting a previous sample set.
 	wg.Wait()
-
-	// TODO(fabxc): maybe this can be released earlier with subsequent refactoring.
-	sp.mtx.Unlock()
 }
 
 // providersFromConfig returns all TargetProviders configured in cfg.
@@ -459,6 +744,10 @@ func providersFromConfig(cfg *config.ScrapeConfig) map[string]TargetProvider {
 		app("static", 0, NewStaticProvider(cfg.TargetGroups))
 	}
 
+	// Third-party discovery mechanisms registered via RegisterDiscovery pull
+	// whatever settings they need out of cfg themselves.
+	pluginProvidersFromConfig(cfg, app)
+
 	return providers
 }
 
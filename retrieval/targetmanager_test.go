@@ -0,0 +1,144 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func newStaticScrapeConfig(job string, interval time.Duration, groups ...*config.TargetGroup) *config.ScrapeConfig {
+	return &config.ScrapeConfig{
+		JobName:        job,
+		Scheme:         "http",
+		ScrapeInterval: config.Duration(interval),
+		TargetGroups:   groups,
+	}
+}
+
+func TestApplyConfigNoopReloadKeepsTargetSet(t *testing.T) {
+	tm := NewTargetManager(nopAppender{})
+	cfg1 := newStaticScrapeConfig("job1", time.Second, &config.TargetGroup{
+		Targets: []model.LabelSet{{model.AddressLabel: "localhost:1"}},
+	})
+
+	tm.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{cfg1}})
+	go tm.Run()
+	defer tm.Stop()
+
+	// Give Run a moment to create the target set.
+	time.Sleep(10 * time.Millisecond)
+
+	tm.mtx.RLock()
+	ts := tm.targetSets["job1"]
+	tm.mtx.RUnlock()
+	if ts == nil {
+		t.Fatal("expected target set for job1 to exist")
+	}
+	scraper := singleTarget(t, ts.scrapePool)
+
+	// Re-applying the exact same config must not replace the target set or
+	// restart its scraper.
+	tm.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{cfg1}})
+
+	tm.mtx.RLock()
+	tsAfter := tm.targetSets["job1"]
+	tm.mtx.RUnlock()
+
+	if ts != tsAfter {
+		t.Fatalf("expected target set identity to be preserved across a no-op reload")
+	}
+	if got := singleTarget(t, tsAfter.scrapePool); got != scraper {
+		t.Fatalf("expected the running scraper to be preserved across a no-op reload")
+	}
+}
+
+func TestApplyConfigOnlyRestartsChangedJob(t *testing.T) {
+	tm := NewTargetManager(nopAppender{})
+	job1 := newStaticScrapeConfig("job1", time.Second, &config.TargetGroup{
+		Targets: []model.LabelSet{{model.AddressLabel: "localhost:1"}},
+	})
+	job2 := newStaticScrapeConfig("job2", time.Second, &config.TargetGroup{
+		Targets: []model.LabelSet{{model.AddressLabel: "localhost:2"}},
+	})
+
+	tm.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{job1, job2}})
+	go tm.Run()
+	defer tm.Stop()
+
+	time.Sleep(10 * time.Millisecond)
+
+	tm.mtx.RLock()
+	ts1Before := tm.targetSets["job1"]
+	ts2Before := tm.targetSets["job2"]
+	tm.mtx.RUnlock()
+
+	job1Scraper := singleTarget(t, ts1Before.scrapePool)
+	job2ScraperBefore := singleTarget(t, ts2Before.scrapePool)
+
+	// Change job2's discovery mechanism; job1 stays untouched.
+	job2Changed := newStaticScrapeConfig("job2", time.Second, &config.TargetGroup{
+		Targets: []model.LabelSet{{model.AddressLabel: "localhost:3"}},
+	})
+	tm.ApplyConfig(&config.Config{ScrapeConfigs: []*config.ScrapeConfig{job1, job2Changed}})
+
+	tm.mtx.RLock()
+	ts1After := tm.targetSets["job1"]
+	ts2After := tm.targetSets["job2"]
+	tm.mtx.RUnlock()
+
+	if ts1Before != ts1After {
+		t.Fatalf("expected job1's target set to be untouched by job2's reload")
+	}
+	if ts2Before != ts2After {
+		t.Fatalf("expected job2's target set to be reused, just reconfigured")
+	}
+
+	if got := singleTarget(t, ts1After.scrapePool); got != job1Scraper {
+		t.Fatalf("expected job1's scraper to keep running unrestarted while only job2 changed")
+	}
+	job2ScraperAfter := singleTarget(t, ts2After.scrapePool)
+	if job2ScraperAfter == job2ScraperBefore {
+		t.Fatalf("expected job2's old scraper to be stopped once its target providers restarted")
+	}
+	if addr := job2ScraperAfter.Labels()[model.AddressLabel]; addr != "localhost:3" {
+		t.Fatalf("expected job2's new scraper to target localhost:3, got %s", addr)
+	}
+}
+
+// singleTarget returns the lone target currently running in sp, failing the
+// test if there isn't exactly one.
+func singleTarget(t *testing.T, sp *scrapePool) *Target {
+	t.Helper()
+
+	sp.mtx.RLock()
+	defer sp.mtx.RUnlock()
+
+	if len(sp.targets) != 1 {
+		t.Fatalf("expected exactly one running target, got %d", len(sp.targets))
+	}
+	for _, tgt := range sp.targets {
+		return tgt
+	}
+	panic("unreachable")
+}
+
+// nopAppender discards every sample it is given.
+type nopAppender struct{}
+
+func (nopAppender) Append(*model.Sample) error { return nil }